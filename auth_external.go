@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// externalAuthorizer delegates the allow/deny decision to an operator-owned HTTP endpoint,
+// for deployments whose access rules live outside a JWT claim (an existing entitlements
+// service, a database-backed ACL, etc).
+type externalAuthorizer struct {
+	url    string
+	client *http.Client
+}
+
+type externalAuthRequest struct {
+	Source string `json:"source"`
+	Scope  string `json:"scope"`
+	Token  string `json:"token"`
+	Addr   string `json:"addr"`
+}
+
+func (a *externalAuthorizer) Authorize(req *http.Request, source string, scope string) error {
+	payload, err := json.Marshal(externalAuthRequest{
+		Source: source,
+		Scope:  scope,
+		Token:  bearerToken(req),
+		Addr:   req.RemoteAddr,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := a.client.Post(a.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("auth callback failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized:
+		return ErrUnauthenticated
+	default:
+		return ErrForbidden
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// jwtClaims is the shape sseredis expects a subscription JWT to carry. Channels is the default
+// glob list for both scopes; PubChannels/SubChannels, if present, override it for that one scope,
+// so a token can be issued read-only or write-only.
+type jwtClaims struct {
+	jwt.StandardClaims
+	Channels    []string `json:"channels"`
+	PubChannels []string `json:"pub"`
+	SubChannels []string `json:"sub"`
+}
+
+// jwtAuthorizer validates a bearer JWT (HS256 via a shared secret, or RS256 via a JWKS endpoint)
+// and checks the source against the token's channel globs for the requested scope.
+type jwtAuthorizer struct {
+	keyFunc jwt.Keyfunc
+}
+
+func newJWTAuthorizer(secret string, jwksURL string) (*jwtAuthorizer, error) {
+	switch {
+	case secret != "":
+		key := []byte(secret)
+		return &jwtAuthorizer{
+			keyFunc: func(token *jwt.Token) (interface{}, error) {
+				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+					return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+				}
+				return key, nil
+			},
+		}, nil
+
+	case jwksURL != "":
+		keys, err := fetchJWKS(jwksURL)
+		if err != nil {
+			return nil, err
+		}
+		return &jwtAuthorizer{
+			keyFunc: func(token *jwt.Token) (interface{}, error) {
+				if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+					return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+				}
+				kid, _ := token.Header["kid"].(string)
+				key, ok := keys[kid]
+				if !ok {
+					return nil, fmt.Errorf("unknown key id: %s", kid)
+				}
+				return key, nil
+			},
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("jwt authorizer requires a --jwt-secret or --jwt-jwks-url")
+	}
+}
+
+func (a *jwtAuthorizer) Authorize(req *http.Request, source string, scope string) error {
+	tokenString := bearerToken(req)
+	if tokenString == "" {
+		return ErrUnauthenticated
+	}
+
+	claims := &jwtClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, a.keyFunc)
+	if err != nil || !token.Valid {
+		return ErrUnauthenticated
+	}
+
+	allowed := claims.Channels
+	switch scope {
+	case "pub":
+		if len(claims.PubChannels) > 0 {
+			allowed = claims.PubChannels
+		}
+	case "sub":
+		if len(claims.SubChannels) > 0 {
+			allowed = claims.SubChannels
+		}
+	}
+
+	for _, pattern := range allowed {
+		if matched, _ := path.Match(pattern, source); matched {
+			return nil
+		}
+	}
+
+	return ErrForbidden
+}
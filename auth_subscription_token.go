@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// subscriptionTokenAuthorizer checks a short-lived, HMAC-signed token scoped to exactly one
+// source and scope. It's meant to be handed to a browser client instead of the main JWT/external
+// secret, so a leaked token can't be replayed against any other channel or used past its expiry.
+type subscriptionTokenAuthorizer struct {
+	secret []byte
+}
+
+func (a *subscriptionTokenAuthorizer) Authorize(req *http.Request, source string, scope string) error {
+	token := bearerToken(req)
+	if token == "" {
+		return ErrUnauthenticated
+	}
+
+	if !verifySubscriptionToken(a.secret, token, source, scope) {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// IssueSubscriptionToken mints a token granting scope ("pub" or "sub") access to source until
+// expiresAt. The payload is plaintext (source/scope/expiry are not secrets); the HMAC is what
+// stops a client from editing any of them.
+func IssueSubscriptionToken(secret []byte, source string, scope string, expiresAt time.Time) string {
+	payload := subscriptionTokenPayload(source, scope, expiresAt)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	signature := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + signature
+}
+
+func verifySubscriptionToken(secret []byte, token string, source string, scope string) bool {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return false
+	}
+
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	payload := string(payloadBytes)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payloadBytes)
+	expectedSignature := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	if subtle.ConstantTimeCompare([]byte(expectedSignature), []byte(parts[1])) != 1 {
+		return false
+	}
+
+	tokenSource, tokenScope, expiresAt, ok := parseSubscriptionTokenPayload(payload)
+	if !ok || tokenSource != source || tokenScope != scope {
+		return false
+	}
+
+	return time.Now().Before(expiresAt)
+}
+
+func subscriptionTokenPayload(source string, scope string, expiresAt time.Time) string {
+	return fmt.Sprintf("%s|%s|%d", source, scope, expiresAt.Unix())
+}
+
+func parseSubscriptionTokenPayload(payload string) (source string, scope string, expiresAt time.Time, ok bool) {
+	parts := strings.SplitN(payload, "|", 3)
+	if len(parts) != 3 {
+		return "", "", time.Time{}, false
+	}
+
+	expiresUnix, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", "", time.Time{}, false
+	}
+
+	return parts[0], parts[1], time.Unix(expiresUnix, 0), true
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Broker is the common transport abstraction behind every prefix sseredis serves: a way to
+// publish a request body into a source, and a way to subscribe to one. universalHandler looks up
+// a Broker by URL prefix rather than hard-coding pubsub vs. stream handling, so deployments that
+// don't want to run Redis (or want several transports side by side) can mount whichever
+// implementations they need under different prefixes.
+type Broker interface {
+	Publish(source string, req *http.Request) (string, error)
+	Subscribe(source string, lastId string, query url.Values) *receiver
+}
+
+// redisPubSubBroker adapts the existing fire-and-forget Redis pub/sub sender and receiver to the
+// Broker interface.
+type redisPubSubBroker struct {
+	client redis.UniversalClient
+}
+
+func (b *redisPubSubBroker) Publish(source string, req *http.Request) (string, error) {
+	return NewPubSubSender(source, b.client).send(req)
+}
+
+func (b *redisPubSubBroker) Subscribe(source string, lastId string, query url.Values) *receiver {
+	return NewPubSubReceiver(source, b.client)
+}
+
+// redisStreamBroker adapts the Redis Streams sender/receiver pair, including the consumer-group
+// path, to the Broker interface. group/consumer act as defaults that a request's own ?group=/
+// ?consumer= query params take precedence over.
+type redisStreamBroker struct {
+	client        redis.UniversalClient
+	group         string
+	consumer      string
+	minIdle       time.Duration
+	claimInterval time.Duration
+	replayBatch   int64
+	metrics       *Metrics
+}
+
+func (b *redisStreamBroker) Publish(source string, req *http.Request) (string, error) {
+	return NewStreamSender(source, b.client).send(req)
+}
+
+func (b *redisStreamBroker) Subscribe(source string, lastId string, query url.Values) *receiver {
+	group := query.Get("group")
+	if group == "" {
+		group = b.group
+	}
+	if group == "" {
+		// ?from= takes precedence over Last-Event-ID when both are present, so a client can
+		// explicitly request a replay window independent of where it last disconnected.
+		from := query.Get("from")
+		if from == "" {
+			from = lastId
+		}
+		return NewStreamReceiver(source, from, query.Get("to"), b.client, b.replayBatch, b.metrics)
+	}
+
+	consumer := query.Get("consumer")
+	if consumer == "" {
+		consumer = b.consumer
+	}
+	return NewStreamGroupReceiver(source, group, consumer, b.client, b.minIdle, b.claimInterval, b.metrics)
+}
+
+// mountList collects repeated -mount <prefix>=<broker> flags into a slice, since flag has no
+// built-in support for flags that may be passed more than once.
+type mountList []string
+
+func (m *mountList) String() string {
+	return strings.Join(*m, ",")
+}
+
+func (m *mountList) Set(value string) error {
+	*m = append(*m, value)
+	return nil
+}
+
+// brokerConfig bundles every flag buildBrokers needs to satisfy a -mount, regardless of which
+// broker kinds are actually mounted.
+type brokerConfig struct {
+	redisMode           string
+	redisAddr           string
+	redisSentinelAddrs  string
+	redisMasterName     string
+	redisClusterAddrs   string
+	redisPass           string
+	redisDb             int
+	maxRedisConnections int
+	streamGroup         string
+	streamConsumer      string
+	streamMinIdle       time.Duration
+	streamClaimInterval time.Duration
+	replayBatch         int64
+	leveldbPath         string
+	natsURL             string
+	metricsRegistry     prometheus.Registerer
+	metrics             *Metrics
+	slowClientPolicy    string
+	slowClientBuffer    int
+}
+
+// buildBrokers turns a list of "<prefix>=<broker>" mounts into the map universalHandler routes
+// against. Backing clients (the Redis connection, the NATS connection) are created at most once
+// and shared across every mount that needs them, so e.g. two redis-stream mounts under different
+// prefixes don't open two connection pools.
+func buildBrokers(mounts []string, cfg brokerConfig) (map[string]Broker, error) {
+	brokers := make(map[string]Broker, len(mounts))
+
+	var redisClient redis.UniversalClient
+	getRedisClient := func() (redis.UniversalClient, error) {
+		if redisClient == nil {
+			client, err := newRedisClient(cfg.redisMode, cfg.redisAddr, cfg.redisSentinelAddrs, cfg.redisMasterName, cfg.redisClusterAddrs, cfg.redisPass, cfg.redisDb, cfg.maxRedisConnections)
+			if err != nil {
+				return nil, err
+			}
+			redisClient = client
+			if cfg.metricsRegistry != nil {
+				registerRedisPoolStats(cfg.metricsRegistry, "redis", redisClient)
+			}
+		}
+		return redisClient, nil
+	}
+
+	var sharedNatsBroker *natsBroker
+
+	for _, mount := range mounts {
+		parts := strings.SplitN(mount, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid -mount %q, expected <prefix>=<broker>", mount)
+		}
+		prefix, kind := parts[0], parts[1]
+
+		var broker Broker
+
+		switch kind {
+		case "redis-pubsub":
+			client, err := getRedisClient()
+			if err != nil {
+				return nil, err
+			}
+			broker = &redisPubSubBroker{client: client}
+
+		case "redis-stream":
+			client, err := getRedisClient()
+			if err != nil {
+				return nil, err
+			}
+			broker = &redisStreamBroker{
+				client:        client,
+				group:         cfg.streamGroup,
+				consumer:      cfg.streamConsumer,
+				minIdle:       cfg.streamMinIdle,
+				claimInterval: cfg.streamClaimInterval,
+				replayBatch:   cfg.replayBatch,
+				metrics:       cfg.metrics,
+			}
+
+		case "memory":
+			broker = newMemoryBroker()
+
+		case "leveldb":
+			levelDBBroker, err := newLevelDBBroker(cfg.leveldbPath)
+			if err != nil {
+				return nil, err
+			}
+			broker = levelDBBroker
+
+		case "nats":
+			if sharedNatsBroker == nil {
+				natsBroker, err := newNatsBroker(cfg.natsURL)
+				if err != nil {
+					return nil, err
+				}
+				sharedNatsBroker = natsBroker
+			}
+			broker = sharedNatsBroker
+
+		default:
+			return nil, fmt.Errorf("unknown broker %q in -mount %s", kind, mount)
+		}
+
+		// With a default --stream-group every subscription on a redis-stream mount is a group
+		// read, which hubBroker keeps per-client, so such a mount is not wrapped in a hub.
+		if kind == "redis-stream" && cfg.streamGroup != "" {
+			brokers[prefix] = broker
+			continue
+		}
+
+		// Share one upstream subscription across every local subscriber to the same source
+		// instead of opening one per browser tab; see hubBroker's doc comment for the
+		// group/replay carve-out.
+		brokers[prefix] = newHubBroker(broker, cfg.slowClientPolicy, cfg.slowClientBuffer, cfg.metrics)
+	}
+
+	return brokers, nil
+}
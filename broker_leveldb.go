@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// levelDBBroker gives stream-like (persistent, replayable) semantics without a Redis dependency,
+// backed by an embedded LevelDB database. Each published message is stored under a
+// "<source>\x00<id>" key so a per-source range scan replays history in order; Subscribe then
+// polls for new keys past the last one it saw, the same way NewStreamReceiver polls Redis with
+// XREAD.
+type levelDBBroker struct {
+	db *leveldb.DB
+}
+
+func newLevelDBBroker(path string) (*levelDBBroker, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &levelDBBroker{db: db}, nil
+}
+
+func levelDBKey(source string, id string) []byte {
+	return []byte(source + "\x00" + id)
+}
+
+// levelDBSeq disambiguates ids minted in the same nanosecond (plausible under concurrent
+// publishes), which a bare time.Now().UnixNano() id can't: two such publishes would otherwise
+// collide on the same key and the second Put would silently overwrite the first. Both fields are
+// fixed-width so lexicographic order (what Subscribe's range scan relies on) still matches
+// chronological order.
+var levelDBSeq int64
+
+func newLevelDBID() string {
+	return fmt.Sprintf("%019d-%010d", time.Now().UnixNano(), atomic.AddInt64(&levelDBSeq, 1))
+}
+
+func (b *levelDBBroker) Publish(source string, req *http.Request) (string, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return "", err
+	}
+
+	id := newLevelDBID()
+	if err := b.db.Put(levelDBKey(source, id), body, nil); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (b *levelDBBroker) Subscribe(source string, lastId string, query url.Values) *receiver {
+	if lastId == "" {
+		lastId = "0"
+	}
+
+	r := &receiver{
+		source:   source,
+		lastId:   lastId,
+		messages: make(chan message),
+	}
+
+	done := make(chan struct{})
+	r.shutdown = func() error {
+		close(done)
+		return nil
+	}
+
+	go func() {
+		prefix := []byte(source + "\x00")
+
+		for {
+			select {
+			case <-done:
+				close(r.messages)
+				return
+			default:
+			}
+
+			found := false
+			iter := b.db.NewIterator(util.BytesPrefix(prefix), nil)
+			for iter.Next() {
+				id := strings.TrimPrefix(string(iter.Key()), source+"\x00")
+				if id <= r.lastId {
+					continue
+				}
+
+				found = true
+				r.messages <- message{
+					source: source,
+					id:     id,
+					text:   string(iter.Value()),
+				}
+				r.lastId = id
+			}
+			iter.Release()
+
+			if !found {
+				select {
+				case <-done:
+					close(r.messages)
+					return
+				case <-time.After(200 * time.Millisecond):
+				}
+			}
+		}
+	}()
+
+	return r
+}
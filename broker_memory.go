@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+// memoryBroker is an in-process, non-durable fan-out broker: Publish delivers to whichever local
+// subscribers are currently connected and drops the message otherwise. It needs no external
+// service, which makes it a good fit for small deployments and for tests.
+type memoryBroker struct {
+	mu   sync.Mutex
+	subs map[string]map[chan message]struct{}
+}
+
+func newMemoryBroker() *memoryBroker {
+	return &memoryBroker{subs: make(map[string]map[chan message]struct{})}
+}
+
+func (b *memoryBroker) Publish(source string, req *http.Request) (string, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return "", err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delivered := 0
+	for ch := range b.subs[source] {
+		select {
+		case ch <- message{source: source, text: string(body)}:
+			delivered++
+		default:
+			// Slow subscriber; drop rather than block the publisher.
+		}
+	}
+
+	return strconv.Itoa(delivered), nil
+}
+
+func (b *memoryBroker) Subscribe(source string, lastId string, query url.Values) *receiver {
+	ch := make(chan message, 16)
+
+	b.mu.Lock()
+	if b.subs[source] == nil {
+		b.subs[source] = make(map[chan message]struct{})
+	}
+	b.subs[source][ch] = struct{}{}
+	b.mu.Unlock()
+
+	r := &receiver{
+		source:   source,
+		messages: make(chan message),
+	}
+	r.shutdown = func() error {
+		b.mu.Lock()
+		delete(b.subs[source], ch)
+		b.mu.Unlock()
+		close(ch)
+		return nil
+	}
+
+	go func() {
+		for msg := range ch {
+			r.messages <- msg
+		}
+		close(r.messages)
+	}()
+
+	return r
+}
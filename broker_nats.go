@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsBroker adapts a NATS JetStream subject to the Broker interface, giving operators a
+// persistent, horizontally-scalable alternative to the Redis-backed brokers. Each source maps to
+// a JetStream subject of the same name; the stream itself is expected to already exist (or be
+// auto-vivified by a permissive JetStream domain) since provisioning retention policy is an
+// operator decision, not one sseredis should make.
+type natsBroker struct {
+	nc *nats.Conn
+	js nats.JetStreamContext
+}
+
+func newNatsBroker(natsURL string) (*natsBroker, error) {
+	nc, err := nats.Connect(natsURL)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := nc.JetStream()
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	return &natsBroker{nc: nc, js: js}, nil
+}
+
+func (b *natsBroker) Publish(source string, req *http.Request) (string, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return "", err
+	}
+
+	ack, err := b.js.Publish(source, body)
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatUint(ack.Sequence, 10), nil
+}
+
+func (b *natsBroker) Subscribe(source string, lastId string, query url.Values) *receiver {
+	r := &receiver{
+		source:   source,
+		messages: make(chan message),
+	}
+
+	opts := []nats.SubOpt{nats.DeliverNew()}
+	if seq, err := strconv.ParseUint(lastId, 10, 64); err == nil && seq > 0 {
+		opts = []nats.SubOpt{nats.StartSequence(seq + 1)}
+	}
+	opts = append(opts, nats.ManualAck())
+
+	// Mirror the ack discipline redis-stream-group uses: a message is only JetStream-acked
+	// once the caller's receiver.ack is invoked, which the subscriber loop only does after the
+	// message has actually been written and flushed to the SSE client. Acking inline in the
+	// subscribe callback (as this used to) would tell JetStream the message was delivered
+	// before that's actually true, losing it on a crash between the two.
+	var pendingMu sync.Mutex
+	pending := make(map[string]*nats.Msg)
+
+	sub, err := b.js.Subscribe(source, func(msg *nats.Msg) {
+		id := ""
+		if meta, err := msg.Metadata(); err == nil {
+			id = strconv.FormatUint(meta.Sequence.Stream, 10)
+		}
+
+		pendingMu.Lock()
+		pending[id] = msg
+		pendingMu.Unlock()
+
+		r.messages <- message{
+			source: source,
+			id:     id,
+			text:   string(msg.Data),
+		}
+	}, opts...)
+	if err != nil {
+		logger.Error("nats subscribe failed", "source", source, "error", err)
+		close(r.messages)
+		return r
+	}
+
+	r.ack = func(id string) error {
+		pendingMu.Lock()
+		msg, ok := pending[id]
+		if ok {
+			delete(pending, id)
+		}
+		pendingMu.Unlock()
+		if !ok {
+			return nil
+		}
+		return msg.Ack()
+	}
+
+	r.shutdown = sub.Unsubscribe
+
+	return r
+}
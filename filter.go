@@ -0,0 +1,83 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// filterReceiver wraps r so that messages are field-projected (?fields=a,b) and pattern-matched
+// (?match=field=glob) before reaching the SSE write loop, without any Broker implementation
+// needing to know about either feature. A message whose field fails the match is dropped (and,
+// if r has an ack function, acked anyway, since it was successfully processed rather than lost).
+func filterReceiver(r *receiver, fields []string, matchField string, matchGlob string) *receiver {
+	if len(fields) == 0 && matchField == "" {
+		return r
+	}
+
+	filtered := &receiver{
+		source:   r.source,
+		messages: make(chan message),
+		shutdown: r.shutdown,
+		ack:      r.ack,
+	}
+
+	go func() {
+		for msg := range r.messages {
+			values := parseFieldValues(msg.text)
+
+			if matchField != "" {
+				matched, err := path.Match(matchGlob, values[matchField])
+				if err != nil || !matched {
+					if r.ack != nil {
+						if err := r.ack(msg.id); err != nil {
+							logger.Error("ack failed", "source", r.source, "error", err)
+						}
+					}
+					continue
+				}
+			}
+
+			if len(fields) > 0 {
+				lines := make([]string, 0, len(fields))
+				for _, field := range fields {
+					if val, ok := values[field]; ok {
+						lines = append(lines, field+"="+val)
+					}
+				}
+				if len(lines) == 0 {
+					// msg.text == "" is the subscriber loop's sentinel for "the receiver
+					// channel closed" (sseredis.go). A message whose requested fields just
+					// happen not to be present isn't that; ack and drop it like a
+					// match-miss instead of forwarding a text indistinguishable from shutdown.
+					if r.ack != nil {
+						if err := r.ack(msg.id); err != nil {
+							logger.Error("ack failed", "source", r.source, "error", err)
+						}
+					}
+					continue
+				}
+				msg.text = strings.Join(lines, "\n")
+			}
+
+			filtered.messages <- msg
+		}
+		close(filtered.messages)
+	}()
+
+	return filtered
+}
+
+// parseFieldValues parses the "key=value" lines produced by the stream receivers back into a
+// map, so filterReceiver can look fields up by name. Pub/sub messages (a single opaque payload,
+// not key=value pairs) usually yield no fields, so filterReceiver drops them under ?fields= and
+// under any ?match= whose glob does not match an empty value.
+func parseFieldValues(text string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 {
+			values[parts[0]] = parts[1]
+		}
+	}
+	return values
+}
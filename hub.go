@@ -0,0 +1,180 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// hubBroker wraps another Broker so that local subscribers to the same source share a single
+// upstream subscription (one SUBSCRIBE/XREAD) instead of each browser tab opening its own Redis
+// connection and goroutine. The first Subscribe for a source opens the real upstream receiver and
+// starts a pump goroutine; later Subscribes for the same source just register another local fan-out
+// channel. The last local subscriber to leave tears the upstream subscription down.
+//
+// Group reads (?group=), bounded replay windows (?from=/?to=), and a Last-Event-ID reconnect
+// (lastId, from the standard SSE reconnect header — see subscriber() in sseredis.go) are
+// inherently per-client: a group subscription carries its own consumer identity and PEL, and a
+// replay window or reconnect catch-up only makes sense for the client that asked for it. Sharing
+// any of these across subscribers would silently hand one client's delivery state to another, so
+// all three bypass the hub and call inner.Subscribe directly. Only a plain live-tail subscription
+// (pubsub, or a stream subscriber not asking for a bounded replay or reconnect) is multiplexed.
+type hubBroker struct {
+	inner Broker
+
+	slowClientPolicy string // "drop" (default) or "close"
+	bufferSize       int
+	metrics          *Metrics
+
+	mu      sync.Mutex
+	sources map[string]*hubSource
+}
+
+// hubSource is the shared state for one upstream subscription: the real receiver and the set of
+// local subscriber channels currently fed from it.
+type hubSource struct {
+	upstream *receiver
+
+	mu          sync.Mutex
+	subscribers map[chan message]struct{}
+}
+
+// newHubBroker wraps inner with hub fan-out. slowClientPolicy is "drop" or "close"; buffer sizes
+// the per-subscriber channel (matching memoryBroker's local fan-out buffer).
+func newHubBroker(inner Broker, slowClientPolicy string, buffer int, metrics *Metrics) *hubBroker {
+	if slowClientPolicy != "close" {
+		slowClientPolicy = "drop"
+	}
+	if buffer <= 0 {
+		buffer = 16
+	}
+
+	return &hubBroker{
+		inner:            inner,
+		slowClientPolicy: slowClientPolicy,
+		bufferSize:       buffer,
+		metrics:          metrics,
+		sources:          make(map[string]*hubSource),
+	}
+}
+
+// parseSlowClientFlag splits "drop", "close", or "buffer=N" into a policy name and buffer size, so
+// --slow-client can set both in a single flag the way --mount packs <prefix>=<broker>.
+func parseSlowClientFlag(value string) (policy string, buffer int) {
+	policy, buffer = "drop", 0
+	for _, part := range strings.Split(value, ",") {
+		switch {
+		case part == "drop" || part == "close":
+			policy = part
+		case strings.HasPrefix(part, "buffer="):
+			if n, err := strconv.Atoi(strings.TrimPrefix(part, "buffer=")); err == nil && n > 0 {
+				buffer = n
+			}
+		}
+	}
+	return policy, buffer
+}
+
+func (b *hubBroker) Publish(source string, req *http.Request) (string, error) {
+	return b.inner.Publish(source, req)
+}
+
+func (b *hubBroker) Subscribe(source string, lastId string, query url.Values) *receiver {
+	if lastId != "" || query.Get("group") != "" || query.Get("from") != "" || query.Get("to") != "" {
+		return b.inner.Subscribe(source, lastId, query)
+	}
+
+	b.mu.Lock()
+	hs, ok := b.sources[source]
+	if !ok {
+		hs = &hubSource{
+			upstream:    b.inner.Subscribe(source, lastId, query),
+			subscribers: make(map[chan message]struct{}),
+		}
+		b.sources[source] = hs
+		go b.pump(source, hs)
+	}
+	b.mu.Unlock()
+
+	client := make(chan message, b.bufferSize)
+	hs.mu.Lock()
+	hs.subscribers[client] = struct{}{}
+	hs.mu.Unlock()
+
+	if b.metrics != nil {
+		b.metrics.hubSubscribers.WithLabelValues(source).Inc()
+	}
+
+	return &receiver{
+		source:   source,
+		lastId:   lastId,
+		messages: client,
+		ack:      hs.upstream.ack,
+		shutdown: func() error {
+			b.leave(source, hs, client)
+			return nil
+		},
+	}
+}
+
+// pump is the only goroutine reading hs.upstream.messages; it fans each message out to every
+// locally registered subscriber. A subscriber whose buffer is already full is handled per
+// slowClientPolicy instead of blocking the pump (and therefore every other subscriber on source).
+func (b *hubBroker) pump(source string, hs *hubSource) {
+	for msg := range hs.upstream.messages {
+		hs.mu.Lock()
+		for client := range hs.subscribers {
+			select {
+			case client <- msg:
+			default:
+				if b.metrics != nil {
+					b.metrics.hubSlowClientHits.WithLabelValues(source).Inc()
+				}
+				if b.slowClientPolicy == "close" {
+					delete(hs.subscribers, client)
+					close(client)
+				}
+			}
+		}
+		hs.mu.Unlock()
+	}
+
+	hs.mu.Lock()
+	for client := range hs.subscribers {
+		close(client)
+	}
+	hs.mu.Unlock()
+}
+
+// leave unregisters client and, once source has no local subscribers left, shuts down the shared
+// upstream subscription so an idle channel doesn't hold a Redis connection open forever.
+func (b *hubBroker) leave(source string, hs *hubSource, client chan message) {
+	hs.mu.Lock()
+	if _, ok := hs.subscribers[client]; ok {
+		delete(hs.subscribers, client)
+	}
+	empty := len(hs.subscribers) == 0
+	hs.mu.Unlock()
+
+	if b.metrics != nil {
+		b.metrics.hubSubscribers.WithLabelValues(source).Dec()
+	}
+
+	if !empty {
+		return
+	}
+
+	b.mu.Lock()
+	if b.sources[source] == hs {
+		delete(b.sources, source)
+	}
+	b.mu.Unlock()
+
+	if hs.upstream.shutdown != nil {
+		if err := hs.upstream.shutdown(); err != nil {
+			logger.Error("hub upstream shutdown error", "source", source, "error", err)
+		}
+	}
+}
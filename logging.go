@@ -0,0 +1,21 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"sync/atomic"
+)
+
+// logger replaces the ad-hoc log.Print calls this package used to make with structured JSON
+// logging, so a log aggregator can filter/correlate by request_id, source, and client_addr
+// instead of grepping free-form strings.
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+var requestCounter int64
+
+// nextRequestID hands out a short, monotonically increasing id to tag together every log line
+// produced while handling one HTTP request.
+func nextRequestID() string {
+	return strconv.FormatInt(atomic.AddInt64(&requestCounter, 1), 36)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"github.com/go-redis/redis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics collects everything the /metrics endpoint exposes. It's a plain struct of
+// already-registered collectors rather than package-level globals so buildMetrics can be called
+// exactly once from main and threaded through explicitly, the same way brokers and the authorizer
+// are.
+type Metrics struct {
+	subscribersActive *prometheus.GaugeVec
+	messagesPublished *prometheus.CounterVec
+	messagesDelivered *prometheus.CounterVec
+	publishLatency    *prometheus.HistogramVec
+	keepAlivesSent    *prometheus.CounterVec
+	clientDisconnects *prometheus.CounterVec
+	subscribeErrors   *prometheus.CounterVec
+	publishErrors     *prometheus.CounterVec
+	hubSubscribers    *prometheus.GaugeVec
+	hubSlowClientHits *prometheus.CounterVec
+	streamReadErrors  *prometheus.CounterVec
+}
+
+// buildMetrics registers every sseredis collector against registry and returns the handle used to
+// record them. Called once from main with prometheus.DefaultRegisterer.
+func buildMetrics(registry prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		subscribersActive: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "sseredis_subscribers_active",
+			Help: "Number of currently open SSE subscriber connections, by source.",
+		}, []string{"source"}),
+		messagesPublished: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sseredis_messages_published_total",
+			Help: "Messages accepted by a publisher request, by source.",
+		}, []string{"source"}),
+		messagesDelivered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sseredis_messages_delivered_total",
+			Help: "Messages written to an SSE subscriber connection, by source.",
+		}, []string{"source"}),
+		publishLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "sseredis_publish_latency_seconds",
+			Help: "Time taken for a publisher request's Broker.Publish call to return, by source.",
+		}, []string{"source"}),
+		keepAlivesSent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sseredis_keepalives_sent_total",
+			Help: "Keep-alive comments written to an SSE subscriber connection, by source.",
+		}, []string{"source"}),
+		clientDisconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sseredis_client_disconnects_total",
+			Help: "SSE subscriber connections that ended, by source.",
+		}, []string{"source"}),
+		subscribeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sseredis_subscribe_errors_total",
+			Help: "Errors writing to or acking an SSE subscriber connection, by source.",
+		}, []string{"source"}),
+		publishErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sseredis_publish_errors_total",
+			Help: "Errors returned by Broker.Publish, by source.",
+		}, []string{"source"}),
+		hubSubscribers: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "sseredis_hub_subscribers",
+			Help: "Local subscribers currently sharing a hub's single upstream subscription, by source.",
+		}, []string{"source"}),
+		hubSlowClientHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sseredis_hub_slow_client_total",
+			Help: "Times the hub's slow-client policy (drop or close) fired because a subscriber's buffer was full, by source.",
+		}, []string{"source"}),
+		streamReadErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sseredis_stream_read_errors_total",
+			Help: "Errors from a stream receiver's background XREAD/XREADGROUP/XRANGE calls, by source and op.",
+		}, []string{"source", "op"}),
+	}
+
+	registry.MustRegister(
+		m.subscribersActive,
+		m.messagesPublished,
+		m.messagesDelivered,
+		m.publishLatency,
+		m.keepAlivesSent,
+		m.clientDisconnects,
+		m.subscribeErrors,
+		m.publishErrors,
+		m.hubSubscribers,
+		m.hubSlowClientHits,
+		m.streamReadErrors,
+	)
+
+	return m
+}
+
+// redisPoolStats returns the *redis.PoolStats behind client, or nil if client's concrete type
+// doesn't expose one. UniversalClient itself declares no PoolStats method (only the concrete
+// *redis.Client and *redis.ClusterClient do, per newRedisClient), so this has to type-switch
+// rather than call through the interface.
+func redisPoolStats(client redis.UniversalClient) *redis.PoolStats {
+	switch c := client.(type) {
+	case *redis.Client:
+		return c.PoolStats()
+	case *redis.ClusterClient:
+		return c.PoolStats()
+	default:
+		return nil
+	}
+}
+
+// registerRedisPoolStats exposes client's connection pool counters under the given label, so a
+// dashboard can tell a healthy idle pool apart from one that's saturated or leaking connections.
+// It's a no-op if client's concrete type doesn't support PoolStats.
+func registerRedisPoolStats(registry prometheus.Registerer, label string, client redis.UniversalClient) {
+	if redisPoolStats(client) == nil {
+		return
+	}
+
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "sseredis_redis_pool_hits_total",
+		Help:        "Redis connection pool hits (PoolStats().Hits) for this client.",
+		ConstLabels: prometheus.Labels{"client": label},
+	}, func() float64 { return float64(redisPoolStats(client).Hits) }))
+
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "sseredis_redis_pool_misses_total",
+		Help:        "Redis connection pool misses (PoolStats().Misses) for this client.",
+		ConstLabels: prometheus.Labels{"client": label},
+	}, func() float64 { return float64(redisPoolStats(client).Misses) }))
+
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "sseredis_redis_pool_timeouts_total",
+		Help:        "Redis connection pool timeouts (PoolStats().Timeouts) for this client.",
+		ConstLabels: prometheus.Labels{"client": label},
+	}, func() float64 { return float64(redisPoolStats(client).Timeouts) }))
+
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "sseredis_redis_pool_total_conns",
+		Help:        "Redis connection pool size (PoolStats().TotalConns) for this client.",
+		ConstLabels: prometheus.Labels{"client": label},
+	}, func() float64 { return float64(redisPoolStats(client).TotalConns) }))
+
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "sseredis_redis_pool_idle_conns",
+		Help:        "Redis connection pool idle connections (PoolStats().IdleConns) for this client.",
+		ConstLabels: prometheus.Labels{"client": label},
+	}, func() float64 { return float64(redisPoolStats(client).IdleConns) }))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-redis/redis"
+)
+
+// newRedisClient builds a redis.UniversalClient for the requested connection mode. Single and
+// sentinel modes return a *redis.Client (the latter backed by Sentinel-aware failover, per
+// redis.NewFailoverClient), while cluster mode returns a *redis.ClusterClient; all three satisfy
+// UniversalClient, so the rest of the program never needs to know which one it holds.
+func newRedisClient(mode string, addr string, sentinelAddrs string, masterName string, clusterAddrs string, pass string, db int, poolSize int) (redis.UniversalClient, error) {
+	switch mode {
+	case "single":
+		return redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: pass,
+			DB:       db,
+			PoolSize: poolSize,
+		}), nil
+
+	case "sentinel":
+		if masterName == "" || sentinelAddrs == "" {
+			return nil, fmt.Errorf("sentinel mode requires --redis-master-name and --redis-sentinel-addrs")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    masterName,
+			SentinelAddrs: splitAddrs(sentinelAddrs),
+			Password:      pass,
+			DB:            db,
+			PoolSize:      poolSize,
+		}), nil
+
+	case "cluster":
+		if clusterAddrs == "" {
+			return nil, fmt.Errorf("cluster mode requires --redis-cluster-addrs")
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:    splitAddrs(clusterAddrs),
+			Password: pass,
+			PoolSize: poolSize,
+		}), nil
+
+	default:
+		return nil, fmt.Errorf("unknown redis-mode: %s", mode)
+	}
+}
+
+func splitAddrs(addrs string) []string {
+	parts := strings.Split(addrs, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
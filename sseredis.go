@@ -6,22 +6,26 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"mime"
 	"net/http"
+	"os"
 	"path"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type universalHandler struct {
-	client          *redis.Client
-	pubsubPrefix    string
-	streamPrefix    string
+	brokers         map[string]Broker
+	authorizer      Authorizer
+	metrics         *Metrics
 	keepAliveTime   time.Duration
 	clientRetryTime string
 }
@@ -42,31 +46,63 @@ type receiver struct {
 	lastId   string
 	messages chan message
 	shutdown func() error
+	ack      func(id string) error
 }
 
-func NewPubSubReceiver(source string, client *redis.Client) *receiver {
+func NewPubSubReceiver(source string, client redis.UniversalClient) *receiver {
 	receiver := &receiver{
 		source:   source,
 		messages: make(chan message),
 	}
 
-	go func() {
-		pubsub := client.Subscribe(source)
-		receiver.shutdown = pubsub.Close
+	done := make(chan struct{})
+	var pubsubMu sync.Mutex
+	var pubsub *redis.PubSub
+	receiver.shutdown = func() error {
+		close(done)
+		pubsubMu.Lock()
+		defer pubsubMu.Unlock()
+		if pubsub != nil {
+			return pubsub.Close()
+		}
+		return nil
+	}
 
-		channel := pubsub.Channel()
+	go func() {
+	resubscribe:
 		for {
-			evt := <-channel
-			if evt == nil {
-				break
-			}
-			if evt.Payload == "" {
-				continue
+			select {
+			case <-done:
+				break resubscribe
+			default:
 			}
 
-			receiver.messages <- message{
-				source: evt.Channel,
-				text:   evt.Payload,
+			pubsubMu.Lock()
+			pubsub = client.Subscribe(source)
+			pubsubMu.Unlock()
+
+			channel := pubsub.Channel()
+			for {
+				evt := <-channel
+				if evt == nil {
+					// The channel closes both on an explicit shutdown and on a connection
+					// loss (e.g. a Sentinel failover); re-subscribe unless we were asked to stop.
+					pubsub.Close()
+					select {
+					case <-done:
+						break resubscribe
+					case <-time.After(time.Second):
+					}
+					continue resubscribe
+				}
+				if evt.Payload == "" {
+					continue
+				}
+
+				receiver.messages <- message{
+					source: evt.Channel,
+					text:   evt.Payload,
+				}
 			}
 		}
 
@@ -76,7 +112,7 @@ func NewPubSubReceiver(source string, client *redis.Client) *receiver {
 	return receiver
 }
 
-func NewPubSubSender(source string, client *redis.Client) *sender {
+func NewPubSubSender(source string, client redis.UniversalClient) *sender {
 	sender := &sender{
 		source: source,
 		send: func(req *http.Request) (string, error) {
@@ -97,20 +133,46 @@ func NewPubSubSender(source string, client *redis.Client) *sender {
 	return sender
 }
 
-func NewStreamReceiver(source string, lastId string, client *redis.Client) *receiver {
-	if lastId == "" {
-		lastId = "0"
+func NewStreamReceiver(source string, from string, to string, client redis.UniversalClient, replayBatch int64, metrics *Metrics) *receiver {
+	if from == "" {
+		from = "0"
+	}
+	if replayBatch <= 0 {
+		replayBatch = 500
 	}
 
 	receiver := &receiver{
 		source:   source,
-		lastId:   lastId,
+		lastId:   from,
 		messages: make(chan message),
 	}
 
+	done := make(chan struct{})
+	receiver.shutdown = func() error {
+		close(done)
+		return nil
+	}
+
 	go func() {
+		if !replayStreamBacklog(receiver, client, source, to, replayBatch, done, metrics) {
+			close(receiver.messages)
+			return
+		}
+		if to != "" {
+			// An explicit ?to= bounds a historical replay window; it doesn't resume live
+			// reading afterwards.
+			close(receiver.messages)
+			return
+		}
+
 	xreader:
 		for {
+			select {
+			case <-done:
+				break xreader
+			default:
+			}
+
 			xrr, err := client.XRead(&redis.XReadArgs{
 				Block: time.Duration(100) * time.Millisecond,
 				Streams: []string{
@@ -123,9 +185,19 @@ func NewStreamReceiver(source string, lastId string, client *redis.Client) *rece
 					continue xreader
 				}
 
-				msg := "Stream Receive Failed: " + err.Error()
-				log.Print(msg)
-				break xreader
+				// A connection error (e.g. mid-failover) is retried with a short backoff
+				// rather than torn down, so a Sentinel/Cluster master switchover doesn't
+				// drop the SSE connection.
+				logger.Error("stream receive failed", "source", source, "error", err)
+				if metrics != nil {
+					metrics.streamReadErrors.WithLabelValues(source, "xread").Inc()
+				}
+				select {
+				case <-done:
+					break xreader
+				case <-time.After(time.Second):
+				}
+				continue xreader
 			}
 
 			for _, wad := range xrr {
@@ -154,7 +226,62 @@ func NewStreamReceiver(source string, lastId string, client *redis.Client) *rece
 	return receiver
 }
 
-func NewStreamSender(source string, client *redis.Client) *sender {
+// replayStreamBacklog drains everything from receiver.lastId (exclusive, once already seen) up
+// to `to` (or the current tail if `to` is empty) in batches of replayBatch via XRANGE, updating
+// receiver.lastId as it goes. This lets a client that reconnects with a stale Last-Event-ID, or
+// asks for an explicit ?from=/?to= window, catch up in a handful of round trips instead of via
+// one-message-at-a-time blocking XREAD calls. Returns false if the caller should stop (shutdown
+// requested or the range read failed).
+func replayStreamBacklog(receiver *receiver, client redis.UniversalClient, source string, to string, replayBatch int64, done chan struct{}, metrics *Metrics) bool {
+	rangeTo := to
+	if rangeTo == "" {
+		rangeTo = "+"
+	}
+
+	for {
+		select {
+		case <-done:
+			return false
+		default:
+		}
+
+		start := receiver.lastId
+		if start != "0" {
+			start = "(" + start
+		}
+
+		batch, err := client.XRangeN(source, start, rangeTo, replayBatch).Result()
+		if err != nil {
+			logger.Error("stream replay failed", "source", source, "error", err)
+			if metrics != nil {
+				metrics.streamReadErrors.WithLabelValues(source, "xrange").Inc()
+			}
+			return false
+		}
+
+		for _, evt := range batch {
+			lines := make([]string, len(evt.Values))
+			i := 0
+			for key, val := range evt.Values {
+				lines[i] = fmt.Sprintf("%s=%s", key, val)
+				i++
+			}
+
+			receiver.messages <- message{
+				source: source,
+				id:     evt.ID,
+				text:   strings.Join(lines, "\n"),
+			}
+			receiver.lastId = evt.ID
+		}
+
+		if int64(len(batch)) < replayBatch {
+			return true
+		}
+	}
+}
+
+func NewStreamSender(source string, client redis.UniversalClient) *sender {
 	sender := &sender{
 		source: source,
 		send: func(req *http.Request) (string, error) {
@@ -208,39 +335,71 @@ func NewStreamSender(source string, client *redis.Client) *sender {
 	return sender
 }
 
+func (handler *universalHandler) trackSubscribeError(source string) {
+	if handler.metrics != nil {
+		handler.metrics.subscribeErrors.WithLabelValues(source).Inc()
+	}
+}
+
 func (handler *universalHandler) subscriber(res http.ResponseWriter, req *http.Request) {
+	requestId := nextRequestID()
 	prefix := path.Dir(req.URL.Path)
 	source := path.Base(req.URL.Path)
+	log := logger.With("request_id", requestId, "source", source, "client_addr", req.RemoteAddr)
 
 	// https://www.w3.org/TR/2011/WD-eventsource-20110310/#last-event-id
 	lastId := req.Header.Get("Last-Event-ID")
 
-	var receiver *receiver
-
-	switch prefix {
-	case handler.pubsubPrefix:
-		receiver = NewPubSubReceiver(source, handler.client)
-	case handler.streamPrefix:
-		receiver = NewStreamReceiver(source, lastId, handler.client)
-	default:
+	broker, ok := handler.brokers[prefix]
+	if !ok {
 		msg := fmt.Sprint("unhandled path: ", prefix)
-		log.Print(msg)
+		log.Warn(msg)
 		http.Error(res, msg, http.StatusNotFound)
 		return
 	}
 
+	if handler.authorizer != nil {
+		if err := handler.authorizer.Authorize(req, source, "sub"); err != nil {
+			writeAuthError(res, true, err)
+			return
+		}
+	}
+
+	query := req.URL.Query()
+	receiver := broker.Subscribe(source, lastId, query)
+
+	var fields []string
+	if f := query.Get("fields"); f != "" {
+		fields = strings.Split(f, ",")
+	}
+	matchField, matchGlob := "", ""
+	if m := query.Get("match"); m != "" {
+		if parts := strings.SplitN(m, "=", 2); len(parts) == 2 {
+			matchField, matchGlob = parts[0], parts[1]
+		}
+	}
+	receiver = filterReceiver(receiver, fields, matchField, matchGlob)
+
 	flusher, ok := res.(http.Flusher)
 	if !ok {
 		http.Error(res, "Streaming Unsupported", http.StatusInternalServerError)
 		return
 	}
 
+	if handler.metrics != nil {
+		handler.metrics.subscribersActive.WithLabelValues(source).Inc()
+	}
+
 	defer func() {
+		if handler.metrics != nil {
+			handler.metrics.subscribersActive.WithLabelValues(source).Dec()
+			handler.metrics.clientDisconnects.WithLabelValues(source).Inc()
+		}
 		if receiver.shutdown != nil {
 			err := receiver.shutdown()
 			if err != nil {
-				msg := "messenger shutdown error: " + err.Error()
-				log.Print(msg)
+				log.Error("messenger shutdown error", "error", err)
+				handler.trackSubscribeError(source)
 			}
 		}
 	}()
@@ -254,16 +413,16 @@ func (handler *universalHandler) subscriber(res http.ResponseWriter, req *http.R
 
 	_, err := res.Write([]byte(": --->" + strings.Repeat(" ", 2048) + "<--- padding\n\n"))
 	if err != nil {
-		msg := "Padding Transmit Failed: " + err.Error()
-		log.Print(msg)
+		log.Error("padding transmit failed", "error", err)
+		handler.trackSubscribeError(source)
 		return
 	}
 
 	if handler.clientRetryTime != "" {
 		_, err = res.Write([]byte("retry: " + handler.clientRetryTime + "\n\n"))
 		if err != nil {
-			msg := "Retry-time Transmit Failed: " + err.Error()
-			log.Print(msg)
+			log.Error("retry-time transmit failed", "error", err)
+			handler.trackSubscribeError(source)
 			return
 		}
 	}
@@ -287,16 +446,16 @@ func (handler *universalHandler) subscriber(res http.ResponseWriter, req *http.R
 			if msg.id != "" {
 				_, err = res.Write([]byte("id: " + msg.id + "\n"))
 				if err != nil {
-					msg := "Event ID Transmit Failed: " + err.Error()
-					log.Print(msg)
+					log.Error("event id transmit failed", "error", err)
+					handler.trackSubscribeError(source)
 					return
 				}
 			}
 
 			_, err = res.Write([]byte("event: " + msg.source + "\n"))
 			if err != nil {
-				msg := "Event Name Transmit Failed: " + err.Error()
-				log.Print(msg)
+				log.Error("event name transmit failed", "error", err)
+				handler.trackSubscribeError(source)
 				return
 			}
 
@@ -304,24 +463,39 @@ func (handler *universalHandler) subscriber(res http.ResponseWriter, req *http.R
 			for index := range hunks {
 				_, err = res.Write([]byte("data: " + hunks[index] + "\n"))
 				if err != nil {
-					msg := "Message Transmit Failed: " + err.Error()
-					log.Print(msg)
+					log.Error("message transmit failed", "error", err)
+					handler.trackSubscribeError(source)
 					return
 				}
 			}
 			_, err = res.Write([]byte("\n"))
 			if err != nil {
-				msg := "Message Transmit Failed: " + err.Error()
-				log.Print(msg)
+				log.Error("message transmit failed", "error", err)
+				handler.trackSubscribeError(source)
 				return
 			}
+
+			if handler.metrics != nil {
+				handler.metrics.messagesDelivered.WithLabelValues(source).Inc()
+			}
+
+			if receiver.ack != nil {
+				flusher.Flush()
+				if err := receiver.ack(msg.id); err != nil {
+					log.Error("ack failed", "error", err)
+					handler.trackSubscribeError(source)
+				}
+			}
 		case <-timeout:
 			_, err := res.Write([]byte(": keep-alive\n\n"))
 			if err != nil {
-				msg := "Keepalive Transmit Failed: " + err.Error()
-				log.Print(msg)
+				log.Error("keepalive transmit failed", "error", err)
+				handler.trackSubscribeError(source)
 				return
 			}
+			if handler.metrics != nil {
+				handler.metrics.keepAlivesSent.WithLabelValues(source).Inc()
+			}
 		// https://stackoverflow.com/a/53966322
 		case <-req.Context().Done():
 			return
@@ -330,38 +504,50 @@ func (handler *universalHandler) subscriber(res http.ResponseWriter, req *http.R
 }
 
 func (handler *universalHandler) publisher(res http.ResponseWriter, req *http.Request) {
+	requestId := nextRequestID()
 	prefix := path.Dir(req.URL.Path)
 	source := path.Base(req.URL.Path)
+	log := logger.With("request_id", requestId, "source", source, "client_addr", req.RemoteAddr)
 
-	var sender *sender
-
-	switch prefix {
-	case handler.pubsubPrefix:
-		sender = NewPubSubSender(source, handler.client)
-	case handler.streamPrefix:
-		sender = NewStreamSender(source, handler.client)
-	default:
+	broker, ok := handler.brokers[prefix]
+	if !ok {
 		msg := fmt.Sprint("unhandled path: ", prefix)
-		log.Print(msg)
+		log.Warn(msg)
 		http.Error(res, msg, http.StatusNotFound)
 		return
 	}
 
-	result, err := sender.send(req)
+	if handler.authorizer != nil {
+		if err := handler.authorizer.Authorize(req, source, "pub"); err != nil {
+			writeAuthError(res, false, err)
+			return
+		}
+	}
+
+	publishStart := time.Now()
+	result, err := broker.Publish(source, req)
+	if handler.metrics != nil {
+		handler.metrics.publishLatency.WithLabelValues(source).Observe(time.Since(publishStart).Seconds())
+	}
 	if err != nil {
+		if handler.metrics != nil {
+			handler.metrics.publishErrors.WithLabelValues(source).Inc()
+		}
 		msg := fmt.Sprint("Error submitting message: ", err.Error())
-		log.Print(msg)
+		log.Error(msg)
 		http.Error(res, msg, http.StatusInternalServerError)
 		return
 	}
+	if handler.metrics != nil {
+		handler.metrics.messagesPublished.WithLabelValues(source).Inc()
+	}
 
 	res.Header().Set("Cache-Control", "no-cache")
 	res.Header().Set("Content-Type", "text/plain")
 	res.WriteHeader(http.StatusOK)
 	_, err = res.Write([]byte(result))
 	if err != nil {
-		msg := "Publish Response Failed: " + err.Error()
-		log.Print(msg)
+		log.Error("publish response failed", "error", err)
 		return
 	}
 }
@@ -374,66 +560,164 @@ func (handler *universalHandler) ServeHTTP(res http.ResponseWriter, req *http.Re
 		handler.publisher(res, req)
 	default:
 		msg := fmt.Sprint("Invalid method: ", req.Method)
-		log.Print(msg)
+		logger.Warn(msg, "client_addr", req.RemoteAddr)
 		http.Error(res, msg, http.StatusMethodNotAllowed)
 	}
 }
 
 func main() {
-	var redisAddr = flag.String("redis-addr", "localhost:6379", "redis address")
+	var redisMode = flag.String("redis-mode", "single", "redis connection mode: single, sentinel, or cluster")
+	var redisAddr = flag.String("redis-addr", "localhost:6379", "redis address (single mode)")
+	var redisSentinelAddrs = flag.String("redis-sentinel-addrs", "", "comma-separated sentinel addresses (sentinel mode)")
+	var redisMasterName = flag.String("redis-master-name", "", "sentinel master name (sentinel mode)")
+	var redisClusterAddrs = flag.String("redis-cluster-addrs", "", "comma-separated cluster node addresses (cluster mode)")
 	var redisPass = flag.String("redis-pass", "", "redis password")
-	var redisDb = flag.Int("redis-db", -1, "redis database number")
+	var redisDb = flag.Int("redis-db", -1, "redis database number (single and sentinel modes only; cluster does not support SELECT)")
 	var maxRedisConnections = flag.Int("max-redis-connections", 10*runtime.NumCPU(), "maximum number of redis connections in the pool")
 	var listenAddr = flag.String("listen-addr", "localhost:8080", "listen address")
-	var pubsubPrefix = flag.String("pubsub-prefix", "", "pubsub URL prefix")
-	var streamPrefix = flag.String("stream-prefix", "", "stream URL prefix")
+	var pubsubPrefix = flag.String("pubsub-prefix", "", "pubsub URL prefix (shorthand for --mount <prefix>=redis-pubsub)")
+	var streamPrefix = flag.String("stream-prefix", "", "stream URL prefix (shorthand for --mount <prefix>=redis-stream)")
 	var keepAlive = flag.Int("keepalive", 30, "seconds between keep-alive messages (0 to disable)")
 	var clientRetry = flag.Float64("client-retry", 0.0, "seconds for the client to wait before reconnecting (0 to use browser defaults)")
+	var streamGroup = flag.String("stream-group", "", "default consumer group for stream subscriptions (enables XREADGROUP/XACK delivery tracking; overridable per-request with ?group=)")
+	var streamConsumer = flag.String("stream-consumer", "", "default consumer name within the group (overridable per-request with ?consumer=; a name is generated if left empty)")
+	var streamMinIdle = flag.Duration("stream-min-idle", 30*time.Second, "minimum idle time before a pending stream message is claimed from a stalled consumer")
+	var streamClaimInterval = flag.Duration("stream-claim-interval", 15*time.Second, "interval between XPENDING/XCLAIM sweeps for stalled consumers")
+	var replayBatch = flag.Int64("replay-batch", 500, "XRANGE batch size used to catch up a reconnecting stream subscriber (via Last-Event-ID or ?from=) before switching to live XREAD")
+	var mounts mountList
+	flag.Var(&mounts, "mount", "repeatable <prefix>=<broker> mount, broker one of: redis-pubsub, redis-stream, leveldb, memory, nats")
+	var leveldbPath = flag.String("leveldb-path", "./sseredis-data", "directory for the leveldb broker's on-disk database")
+	var natsURL = flag.String("nats-url", nats.DefaultURL, "NATS server URL for the nats broker")
+	var jwtSecret = flag.String("jwt-secret", "", "HMAC secret for validating HS256 subscription JWTs")
+	var jwtJWKSURL = flag.String("jwt-jwks-url", "", "JWKS endpoint for validating RS256 subscription JWTs")
+	var subscriptionSecret = flag.String("subscription-secret", "", "HMAC secret for narrowly-scoped, short-lived subscription tokens (see IssueSubscriptionToken)")
+	var issueTokenFor = flag.String("issue-token-for", "", "mint a --subscription-secret token scoped to this source, print it, and exit, instead of serving")
+	var issueTokenScope = flag.String("issue-token-scope", "sub", "scope for --issue-token-for: pub or sub")
+	var issueTokenTTL = flag.Duration("issue-token-ttl", time.Hour, "how long a token minted by --issue-token-for stays valid")
+	var authURL = flag.String("auth-url", "", "external HTTP callback consulted for every pub/sub authorization decision")
+	var metricsAddr = flag.String("metrics-addr", "", "listen address for the Prometheus /metrics endpoint (disabled if empty; serve on a separate, firewalled listener)")
+	var slowClient = flag.String("slow-client", "drop", "policy for a subscriber whose local buffer fills up: drop (skip the message), close (disconnect it), optionally combined with buffer=N, e.g. \"close,buffer=64\"")
 
 	flag.Parse()
 
-	if *pubsubPrefix == "" && *streamPrefix == "" {
-		log.Fatal("Must set pubsib-prefix or stream-prefix")
+	if *issueTokenFor != "" {
+		if *subscriptionSecret == "" {
+			logger.Error("--issue-token-for requires --subscription-secret")
+			os.Exit(1)
+		}
+		if *issueTokenScope != "pub" && *issueTokenScope != "sub" {
+			logger.Error("--issue-token-scope must be pub or sub")
+			os.Exit(1)
+		}
+		token := IssueSubscriptionToken([]byte(*subscriptionSecret), *issueTokenFor, *issueTokenScope, time.Now().Add(*issueTokenTTL))
+		fmt.Println(token)
+		return
 	}
 
-	log.Print("Redis Address     : ", *redisAddr)
-	log.Print("Redis Password    : ", *redisPass)
-	log.Print("Redis Database    : ", *redisDb)
-	log.Print("Max Connections   : ", *maxRedisConnections)
-	log.Print("Listen Address    : ", *listenAddr)
 	if *pubsubPrefix != "" {
-		log.Print("PubSub URL Prefix : ", *pubsubPrefix)
+		mounts = append(mounts, *pubsubPrefix+"=redis-pubsub")
 	}
 	if *streamPrefix != "" {
-		log.Print("Stream URL Prefix : ", *streamPrefix)
+		mounts = append(mounts, *streamPrefix+"=redis-stream")
+	}
+	if len(mounts) == 0 {
+		logger.Error("must set pubsub-prefix, stream-prefix, or --mount")
+		os.Exit(1)
+	}
+
+	logger.Info("listen address", "addr", *listenAddr)
+	for _, mount := range mounts {
+		logger.Info("mount", "mount", mount)
+	}
+	logger.Info("keep-alive", "seconds", *keepAlive)
+	if *streamGroup != "" {
+		logger.Info("stream group", "group", *streamGroup, "min_idle", *streamMinIdle, "claim_interval", *streamClaimInterval)
 	}
-	log.Print("Keep-Alive        : ", *keepAlive)
 
 	var clientRetryTime string
 	if *clientRetry > 0.0 {
-		log.Print("Client Retry   : ", *clientRetry)
+		logger.Info("client retry", "seconds", *clientRetry)
 		clientRetryTime = strconv.Itoa(int(*clientRetry * 1000.0))
 	}
 
-	client := redis.NewClient(&redis.Options{
-		Addr:     *redisAddr,
-		Password: *redisPass,
-		DB:       *redisDb,
-		PoolSize: *maxRedisConnections,
+	var metrics *Metrics
+	var metricsRegistry prometheus.Registerer
+	if *metricsAddr != "" {
+		metrics = buildMetrics(prometheus.DefaultRegisterer)
+		metricsRegistry = prometheus.DefaultRegisterer
+	}
+
+	slowClientPolicy, slowClientBuffer := parseSlowClientFlag(*slowClient)
+
+	brokers, err := buildBrokers(mounts, brokerConfig{
+		redisMode:           *redisMode,
+		redisAddr:           *redisAddr,
+		redisSentinelAddrs:  *redisSentinelAddrs,
+		redisMasterName:     *redisMasterName,
+		redisClusterAddrs:   *redisClusterAddrs,
+		redisPass:           *redisPass,
+		redisDb:             *redisDb,
+		maxRedisConnections: *maxRedisConnections,
+		streamGroup:         *streamGroup,
+		streamConsumer:      *streamConsumer,
+		streamMinIdle:       *streamMinIdle,
+		streamClaimInterval: *streamClaimInterval,
+		replayBatch:         *replayBatch,
+		leveldbPath:         *leveldbPath,
+		natsURL:             *natsURL,
+		metricsRegistry:     metricsRegistry,
+		metrics:             metrics,
+		slowClientPolicy:    slowClientPolicy,
+		slowClientBuffer:    slowClientBuffer,
 	})
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	authorizer, err := buildAuthorizer(authConfig{
+		jwtSecret:          *jwtSecret,
+		jwtJWKSURL:         *jwtJWKSURL,
+		subscriptionSecret: *subscriptionSecret,
+		authURL:            *authURL,
+	})
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	if authorizer != nil {
+		logger.Info("authorization enabled")
+	}
+
+	if *metricsAddr != "" {
+		go func() {
+			logger.Info("metrics listening", "addr", *metricsAddr)
+			metricsServer := http.Server{
+				Addr:    *metricsAddr,
+				Handler: promhttp.Handler(),
+			}
+			if err := metricsServer.ListenAndServe(); err != nil {
+				logger.Error(err.Error())
+				os.Exit(1)
+			}
+		}()
+	}
 
 	server := http.Server{
 		Addr: *listenAddr,
 		Handler: &universalHandler{
-			client:          client,
-			pubsubPrefix:    *pubsubPrefix,
-			streamPrefix:    *streamPrefix,
+			brokers:         brokers,
+			authorizer:      authorizer,
+			metrics:         metrics,
 			keepAliveTime:   time.Duration(*keepAlive),
 			clientRetryTime: clientRetryTime,
 		},
 	}
 
-	log.Printf("Listening on %s", server.Addr)
+	logger.Info("listening", "addr", server.Addr)
 
-	log.Fatal(server.ListenAndServe())
+	if err := server.ListenAndServe(); err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// streamGroupConsumerCounter disambiguates auto-generated consumer names (see
+// NewStreamGroupReceiver) across concurrent anonymous subscribers in the same process; without
+// it every such subscriber would share one "<host>-<pid>" identity and each would only ever see
+// its own PEL as "everyone else's" stalled entries, since claimStalledMessages skips entries
+// already owned by the reclaiming consumer.
+var streamGroupConsumerCounter int64
+
+// NewStreamGroupReceiver is the consumer-group sibling of NewStreamReceiver. Instead of a
+// free-running XREAD, it joins (creating if necessary) a Redis Streams consumer group and reads
+// with XREADGROUP, which lets Redis track per-message delivery in the group's PEL. Messages are
+// only XACKed by the caller (via receiver.ack) once they have actually been written and flushed
+// to the SSE client, so a dropped connection leaves its in-flight messages pending for redelivery
+// instead of silently losing them.
+func NewStreamGroupReceiver(source string, group string, consumer string, client redis.UniversalClient, minIdle time.Duration, claimInterval time.Duration, metrics *Metrics) *receiver {
+	if consumer == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "sseredis"
+		}
+		consumer = fmt.Sprintf("%s-%d-%s", host, os.Getpid(), strconv.FormatInt(atomic.AddInt64(&streamGroupConsumerCounter, 1), 36))
+	}
+
+	err := client.XGroupCreateMkStream(source, group, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		logger.Error("stream group create failed", "source", source, "group", group, "error", err)
+	}
+
+	receiver := &receiver{
+		source:   source,
+		messages: make(chan message),
+	}
+	receiver.ack = func(id string) error {
+		return client.XAck(source, group, id).Err()
+	}
+
+	done := make(chan struct{})
+	receiver.shutdown = func() error {
+		close(done)
+		return nil
+	}
+
+	go claimStalledMessages(client, source, group, consumer, minIdle, claimInterval, done)
+
+	go func() {
+		// On (re)connect, first drain this consumer's own pending entries list with "0"
+		// before moving on to new messages with ">", so a reconnecting client picks up
+		// anything it was delivered but never acked.
+		readFrom := "0"
+
+		// claimStalledMessages can XCLAIM a stalled entry onto this consumer at any time, and
+		// a claimed entry only shows up via a "0" read of this consumer's own PEL, not a ">"
+		// read. Re-check "0" on the same cadence as the claim sweep so a reclaimed message
+		// doesn't sit undelivered until this connection happens to reconnect.
+		nextPendingCheck := time.Now().Add(claimInterval)
+
+		for {
+			select {
+			case <-done:
+				close(receiver.messages)
+				return
+			default:
+			}
+
+			xrr, err := client.XReadGroup(&redis.XReadGroupArgs{
+				Group:    group,
+				Consumer: consumer,
+				Block:    time.Duration(100) * time.Millisecond,
+				Streams:  []string{source, readFrom},
+			}).Result()
+			if err != nil {
+				if err == redis.Nil {
+					if readFrom != ">" {
+						readFrom = ">"
+					}
+					continue
+				}
+
+				// Retry connection errors (e.g. mid-failover) with a short backoff instead
+				// of tearing the subscription down, so a Sentinel/Cluster switchover doesn't
+				// drop the SSE connection.
+				logger.Error("stream group receive failed", "source", source, "group", group, "consumer", consumer, "error", err)
+				if metrics != nil {
+					metrics.streamReadErrors.WithLabelValues(source, "xreadgroup").Inc()
+				}
+				select {
+				case <-done:
+					close(receiver.messages)
+					return
+				case <-time.After(time.Second):
+				}
+				continue
+			}
+
+			sawPending := false
+			for _, wad := range xrr {
+				for _, evt := range wad.Messages {
+					if readFrom == "0" {
+						sawPending = true
+					}
+
+					lines := make([]string, len(evt.Values))
+					i := 0
+					for key, val := range evt.Values {
+						lines[i] = fmt.Sprintf("%s=%s", key, val)
+						i++
+					}
+
+					receiver.messages <- message{
+						source: wad.Stream,
+						id:     evt.ID,
+						text:   strings.Join(lines, "\n"),
+					}
+				}
+			}
+
+			if readFrom == "0" {
+				if !sawPending {
+					readFrom = ">"
+				}
+			} else if claimInterval > 0 && !time.Now().Before(nextPendingCheck) {
+				readFrom = "0"
+				nextPendingCheck = time.Now().Add(claimInterval)
+			}
+		}
+	}()
+
+	return receiver
+}
+
+// claimStalledMessages periodically scans the group's pending entries list for messages that
+// have sat idle for longer than minIdle and reassigns them to consumer via XCLAIM, so a consumer
+// that died mid-delivery doesn't hold its in-flight messages hostage forever. Claimed messages
+// reappear on the claiming consumer's own PEL and are picked up on its next "0" read.
+func claimStalledMessages(client redis.UniversalClient, source string, group string, consumer string, minIdle time.Duration, interval time.Duration, done chan struct{}) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		pending, err := client.XPendingExt(&redis.XPendingExtArgs{
+			Stream: source,
+			Group:  group,
+			Start:  "-",
+			End:    "+",
+			Count:  100,
+		}).Result()
+		if err != nil {
+			logger.Error("stream pending sweep failed", "source", source, "group", group, "error", err)
+			continue
+		}
+
+		var stalled []string
+		for _, entry := range pending {
+			if entry.Consumer != consumer && entry.Idle >= minIdle {
+				stalled = append(stalled, entry.Id)
+			}
+		}
+
+		if len(stalled) == 0 {
+			continue
+		}
+
+		_, err = client.XClaim(&redis.XClaimArgs{
+			Stream:   source,
+			Group:    group,
+			Consumer: consumer,
+			MinIdle:  minIdle,
+			Messages: stalled,
+		}).Result()
+		if err != nil {
+			logger.Error("stream claim failed", "source", source, "group", group, "consumer", consumer, "error", err)
+		}
+	}
+}